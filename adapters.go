@@ -0,0 +1,125 @@
+package permissions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grengojbo/pinterface"
+)
+
+// AdapterFactory builds a pinterface.IUserState backed storage backend
+// from an adapter-specific configuration string (a DSN, a connection
+// string, a file path, or a "host:port" pair, depending on the adapter).
+type AdapterFactory func(cfg string) (pinterface.IUserState, error)
+
+var (
+	adaptersMu sync.RWMutex
+	adapters   = make(map[string]AdapterFactory)
+)
+
+// RegisterAdapter makes a storage backend available under the given
+// name, for use with Options.Adapter or NewWithDSN. This is how
+// sibling packages (for instance a "bolt" or "postgres" backend living
+// in its own module) plug themselves in from an init function, the
+// same way database/sql drivers register themselves.
+func RegisterAdapter(name string, factory AdapterFactory) {
+	adaptersMu.Lock()
+	defer adaptersMu.Unlock()
+	if factory == nil {
+		panic("permissions: RegisterAdapter factory is nil for adapter " + name)
+	}
+	if _, dup := adapters[name]; dup {
+		panic("permissions: RegisterAdapter called twice for adapter " + name)
+	}
+	adapters[name] = factory
+}
+
+func lookupAdapter(name string) (AdapterFactory, error) {
+	adaptersMu.RLock()
+	defer adaptersMu.RUnlock()
+	factory, found := adapters[name]
+	if !found {
+		return nil, fmt.Errorf("permissions: unknown adapter %q (forgot to import it?)", name)
+	}
+	return factory, nil
+}
+
+// NewWithDSN initializes a Permissions struct using the named storage
+// adapter and an adapter-specific data source name. Only "redis" is
+// registered by this package; other backends (bolt, mysql, mariadb,
+// postgres, ...) can plug in the same way by calling RegisterAdapter
+// from their own package's init function, but none ship here yet.
+func NewWithDSN(adapterName, dsn string) (*Permissions, error) {
+	factory, err := lookupAdapter(adapterName)
+	if err != nil {
+		return nil, err
+	}
+	state, err := factory(dsn)
+	if err != nil {
+		return nil, err
+	}
+	perm := NewPermissions(state)
+	return perm, nil
+}
+
+// GCStater is implemented by backends that need to periodically sweep
+// expired data, such as confirmation codes or stale sessions. Backends
+// that implement it are swept automatically by NewWithConf, using
+// Options.Interval as the sweep period in seconds; NewWithDSN starts
+// no sweeping.
+type GCStater interface {
+	GC()
+}
+
+// startGC launches the periodic sweep goroutine for candidate if it
+// implements GCStater, and is a no-op otherwise. candidate is typically
+// a pinterface.IUserState backend, but NewWithConf also uses it to
+// sweep Permissions' own *confirmationExpiry, which isn't one.
+func startGC(candidate interface{}, intervalSeconds int) {
+	gc, ok := candidate.(GCStater)
+	if !ok {
+		return
+	}
+	if intervalSeconds <= 0 {
+		intervalSeconds = 60
+	}
+	ticker := time.NewTicker(time.Duration(intervalSeconds) * time.Second)
+	go func() {
+		for range ticker.C {
+			gc.GC()
+		}
+	}()
+}
+
+// redisDSN builds the "redis" adapter's DSN format, a database index
+// and a "host:port" pair joined by "@", e.g. "2@127.0.0.1:6379".
+func redisDSN(db int, hostPort string) string {
+	return fmt.Sprintf("%d@%s", db, hostPort)
+}
+
+// parseRedisDSN reverses redisDSN. A cfg with no "@" (for example one
+// set directly via Options.AdapterConfig without a db prefix) is
+// treated as a bare "host:port" with db 0, for backwards compatibility.
+func parseRedisDSN(cfg string) (db int, hostPort string) {
+	hostPort = cfg
+	if at := strings.Index(cfg, "@"); at != -1 {
+		if n, err := strconv.Atoi(cfg[:at]); err == nil {
+			db = n
+		}
+		hostPort = cfg[at+1:]
+	}
+	if hostPort == "" {
+		hostPort = "127.0.0.1:6379"
+	}
+	return db, hostPort
+}
+
+func init() {
+	RegisterAdapter("redis", func(cfg string) (pinterface.IUserState, error) {
+		db, hostPort := parseRedisDSN(cfg)
+		return NewUserState(db, true, hostPort), nil
+	})
+}
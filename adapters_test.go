@@ -0,0 +1,72 @@
+package permissions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grengojbo/pinterface"
+)
+
+func TestRedisDSNRoundTrip(t *testing.T) {
+	dsn := redisDSN(3, "example.com:6380")
+	db, hostPort := parseRedisDSN(dsn)
+	if db != 3 {
+		t.Errorf("expected db 3, got %d", db)
+	}
+	if hostPort != "example.com:6380" {
+		t.Errorf("expected host:port example.com:6380, got %q", hostPort)
+	}
+}
+
+func TestParseRedisDSNBareHostPort(t *testing.T) {
+	db, hostPort := parseRedisDSN("example.com:6380")
+	if db != 0 {
+		t.Errorf("expected db 0 for a bare host:port, got %d", db)
+	}
+	if hostPort != "example.com:6380" {
+		t.Errorf("expected host:port example.com:6380, got %q", hostPort)
+	}
+}
+
+func TestParseRedisDSNEmpty(t *testing.T) {
+	_, hostPort := parseRedisDSN("")
+	if hostPort != "127.0.0.1:6379" {
+		t.Errorf("expected the default host:port for an empty cfg, got %q", hostPort)
+	}
+}
+
+func TestLookupAdapterUnknown(t *testing.T) {
+	if _, err := lookupAdapter("no-such-adapter"); err == nil {
+		t.Errorf("expected an error looking up an unregistered adapter")
+	}
+}
+
+func TestNewWithDSNUsesRegisteredAdapter(t *testing.T) {
+	RegisterAdapter("fake-adapter-for-test", func(cfg string) (pinterface.IUserState, error) {
+		return &fakeState{username: cfg}, nil
+	})
+	perm, err := NewWithDSN("fake-adapter-for-test", "alice")
+	if err != nil {
+		t.Fatalf("NewWithDSN: %s", err)
+	}
+	if got := perm.UserState().(*fakeState).username; got != "alice" {
+		t.Errorf("expected the factory's cfg to reach the backend, got %q", got)
+	}
+}
+
+type fakeGCState struct {
+	swept int
+}
+
+func (f *fakeGCState) GC() { f.swept++ }
+
+func TestStartGCSweepsOnlyGCStaters(t *testing.T) {
+	startGC(&fakeState{}, 1) // doesn't implement GCStater, must not panic
+
+	gc := &fakeGCState{}
+	startGC(gc, 1)
+	time.Sleep(1100 * time.Millisecond)
+	if gc.swept == 0 {
+		t.Errorf("expected the ticker to have called GC at least once")
+	}
+}
@@ -0,0 +1,141 @@
+package permissions
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultConfirmationTTL is how long a confirmation code stays valid
+// when Options.ConfirmationTTL is left at zero.
+const defaultConfirmationTTL = 24 * time.Hour
+
+// confirmationExpiry tracks the TTL side of a confirmation code.
+// pinterface.IUserState already stores the code<->username mapping
+// (AddUnconfirmed/RemoveUnconfirmed/ConfirmUserByConfirmationCode),
+// mirroring permissionbolt/permissionsql/pstore, but none of those
+// backends expire codes on their own, so Permissions tracks expiry here.
+type confirmationExpiry struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	expires map[string]time.Time
+}
+
+func newConfirmationExpiry(ttl time.Duration) *confirmationExpiry {
+	if ttl <= 0 {
+		ttl = defaultConfirmationTTL
+	}
+	return &confirmationExpiry{ttl: ttl, expires: make(map[string]time.Time)}
+}
+
+func (ce *confirmationExpiry) note(code string) {
+	ce.mu.Lock()
+	defer ce.mu.Unlock()
+	ce.expires[code] = time.Now().Add(ce.ttl)
+}
+
+func (ce *confirmationExpiry) forget(code string) {
+	ce.mu.Lock()
+	defer ce.mu.Unlock()
+	delete(ce.expires, code)
+}
+
+func (ce *confirmationExpiry) expired(code string) bool {
+	ce.mu.Lock()
+	defer ce.mu.Unlock()
+	expiresAt, found := ce.expires[code]
+	if !found {
+		// No recorded expiry (e.g. added before this TTL layer
+		// existed), so fall back to never expiring.
+		return false
+	}
+	return time.Now().After(expiresAt)
+}
+
+// GC discards expiry bookkeeping for codes whose TTL has already
+// passed. It satisfies GCStater so it can be swept on Options.Interval
+// alongside the storage backend.
+func (ce *confirmationExpiry) GC() {
+	ce.mu.Lock()
+	defer ce.mu.Unlock()
+	now := time.Now()
+	for code, expiresAt := range ce.expires {
+		if now.After(expiresAt) {
+			delete(ce.expires, code)
+		}
+	}
+}
+
+// confirmationExpiryTracker lazily initializes and returns perm's expiry tracker.
+func (perm *Permissions) confirmationExpiryTracker() *confirmationExpiry {
+	if perm.confirmations == nil {
+		perm.confirmations = newConfirmationExpiry(perm.confirmationTTL)
+	}
+	return perm.confirmations
+}
+
+// SetConfirmationTTL changes how long future confirmation codes stay
+// valid. Codes already issued keep the TTL that was in effect when
+// they were generated.
+func (perm *Permissions) SetConfirmationTTL(ttl time.Duration) {
+	perm.confirmationTTL = ttl
+	if ce := perm.confirmations; ce != nil {
+		if ttl <= 0 {
+			ttl = defaultConfirmationTTL
+		}
+		ce.mu.Lock()
+		ce.ttl = ttl
+		ce.mu.Unlock()
+	}
+}
+
+// GenerateConfirmationCode creates a new, unique confirmation code for
+// username and remembers the username<->code mapping, so the caller
+// can email it as part of a "/confirm/:code" link.
+func (perm *Permissions) GenerateConfirmationCode(username string) (string, error) {
+	code, err := perm.state.GenerateUniqueConfirmationCode()
+	if err != nil {
+		return "", fmt.Errorf("permissions: could not generate confirmation code: %s", err)
+	}
+	perm.AddUnconfirmed(username, code)
+	return code, nil
+}
+
+// AddUnconfirmed records that username is waiting to be confirmed with
+// the given code. Thin wrapper around pinterface.IUserState's own
+// AddUnconfirmed, adding TTL bookkeeping on top.
+func (perm *Permissions) AddUnconfirmed(username, code string) {
+	perm.state.AddUnconfirmed(username, code)
+	perm.confirmationExpiryTracker().note(code)
+}
+
+// RemoveUnconfirmed forgets that username is waiting to be confirmed,
+// without granting user rights. Useful for expiring or cancelling a
+// pending registration.
+func (perm *Permissions) RemoveUnconfirmed(username string) {
+	perm.state.RemoveUnconfirmed(username)
+}
+
+// PendingConfirmation reports whether username has not yet been
+// confirmed, i.e. has not followed a confirmation link. It is also true
+// for a username that was never registered. Thin wrapper around
+// pinterface.IUserState's own IsConfirmed; AlreadyHasConfirmationCode
+// takes a confirmation code, not a username, so it can't answer this.
+func (perm *Permissions) PendingConfirmation(username string) bool {
+	return !perm.state.IsConfirmed(username)
+}
+
+// ConfirmUserByCode looks up the username that was issued code, grants
+// it user rights via the backing store and removes the pending
+// confirmation entry. It returns an error if the code is unknown,
+// already used, or has expired.
+func (perm *Permissions) ConfirmUserByCode(code string) error {
+	if perm.confirmationExpiryTracker().expired(code) {
+		return fmt.Errorf("permissions: confirmation code has expired")
+	}
+	if err := perm.state.ConfirmUserByConfirmationCode(code); err != nil {
+		return err
+	}
+	perm.confirmationExpiryTracker().forget(code)
+	return nil
+}
@@ -0,0 +1,109 @@
+package permissions
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grengojbo/pinterface"
+)
+
+// fakeConfirmState is a minimal pinterface.IUserState backing the
+// confirmation code<->username bookkeeping Permissions delegates to.
+type fakeConfirmState struct {
+	pinterface.IUserState
+	confirmed  map[string]bool
+	codeToUser map[string]string
+	nextCode   string
+}
+
+func newFakeConfirmState() *fakeConfirmState {
+	return &fakeConfirmState{confirmed: map[string]bool{}, codeToUser: map[string]string{}}
+}
+
+func (f *fakeConfirmState) IsConfirmed(username string) bool { return f.confirmed[username] }
+
+func (f *fakeConfirmState) GenerateUniqueConfirmationCode() (string, error) {
+	return f.nextCode, nil
+}
+
+func (f *fakeConfirmState) AddUnconfirmed(username, code string) {
+	f.codeToUser[code] = username
+}
+
+func (f *fakeConfirmState) RemoveUnconfirmed(username string) {
+	for code, u := range f.codeToUser {
+		if u == username {
+			delete(f.codeToUser, code)
+		}
+	}
+}
+
+func (f *fakeConfirmState) AlreadyHasConfirmationCode(code string) bool {
+	_, found := f.codeToUser[code]
+	return found
+}
+
+func (f *fakeConfirmState) ConfirmUserByConfirmationCode(code string) error {
+	username, found := f.codeToUser[code]
+	if !found {
+		return fmt.Errorf("unknown confirmation code")
+	}
+	delete(f.codeToUser, code)
+	f.confirmed[username] = true
+	return nil
+}
+
+func TestGenerateConfirmationCodeThenPendingConfirmation(t *testing.T) {
+	state := newFakeConfirmState()
+	state.nextCode = "abc123"
+	perm := NewPermissions(state)
+
+	if !perm.PendingConfirmation("alice") {
+		t.Errorf("expected alice to be pending before any code was generated")
+	}
+	code, err := perm.GenerateConfirmationCode("alice")
+	if err != nil {
+		t.Fatalf("GenerateConfirmationCode: %s", err)
+	}
+	if !perm.PendingConfirmation("alice") {
+		t.Errorf("expected alice to still be pending right after GenerateConfirmationCode")
+	}
+	if err := perm.ConfirmUserByCode(code); err != nil {
+		t.Fatalf("ConfirmUserByCode: %s", err)
+	}
+	if perm.PendingConfirmation("alice") {
+		t.Errorf("expected alice not to be pending after confirming")
+	}
+}
+
+func TestConfirmUserByCodeRejectsExpiredCode(t *testing.T) {
+	state := newFakeConfirmState()
+	state.nextCode = "abc123"
+	perm := NewPermissions(state)
+	perm.SetConfirmationTTL(time.Millisecond)
+
+	code, err := perm.GenerateConfirmationCode("alice")
+	if err != nil {
+		t.Fatalf("GenerateConfirmationCode: %s", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := perm.ConfirmUserByCode(code); err == nil {
+		t.Errorf("expected an expired confirmation code to be rejected")
+	}
+}
+
+func TestRemoveUnconfirmedCancelsPendingRegistration(t *testing.T) {
+	state := newFakeConfirmState()
+	state.nextCode = "abc123"
+	perm := NewPermissions(state)
+
+	code, err := perm.GenerateConfirmationCode("alice")
+	if err != nil {
+		t.Fatalf("GenerateConfirmationCode: %s", err)
+	}
+	perm.RemoveUnconfirmed("alice")
+	if err := perm.ConfirmUserByCode(code); err == nil {
+		t.Errorf("expected confirming a removed code to fail")
+	}
+}
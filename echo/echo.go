@@ -0,0 +1,24 @@
+// Package echo provides Echo middleware for github.com/grengojbo/permissions2.
+package echo
+
+import (
+	"github.com/grengojbo/permissions2"
+	"github.com/labstack/echo"
+)
+
+// Handler returns Echo middleware that rejects requests perm.Rejected
+// disallows, calling perm's deny function and stopping the chain
+// instead of calling next.
+func Handler(perm *permissions.Permissions) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			w := c.Response()
+			if perm.Rejected(w, req) {
+				perm.DenyFunction()(w, req)
+				return nil
+			}
+			return next(c)
+		}
+	}
+}
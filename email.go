@@ -0,0 +1,63 @@
+package permissions
+
+import (
+	"fmt"
+	"net/smtp"
+)
+
+// EmailSender delivers a confirmation (or any other transactional)
+// email. Applications that already have their own mailer can implement
+// this instead of using SMTPEmailSender.
+type EmailSender interface {
+	Send(to, subject, body string) error
+}
+
+// SMTPEmailSender is the default EmailSender, sending plain-text mail
+// through an SMTP relay.
+type SMTPEmailSender struct {
+	Addr string // "host:port" of the SMTP server
+	Auth smtp.Auth
+	From string
+}
+
+// NewSMTPEmailSender creates an EmailSender that relays mail through
+// the SMTP server at addr, authenticating with auth if given, and
+// setting the From header to from.
+func NewSMTPEmailSender(addr string, auth smtp.Auth, from string) *SMTPEmailSender {
+	return &SMTPEmailSender{Addr: addr, Auth: auth, From: from}
+}
+
+// Send delivers a plain-text email with the given subject and body to
+// the given recipient.
+func (s *SMTPEmailSender) Send(to, subject, body string) error {
+	msg := "From: " + s.From + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"\r\n" + body + "\r\n"
+	return smtp.SendMail(s.Addr, s.Auth, s.From, []string{to}, []byte(msg))
+}
+
+// SetEmailSender wires up the mailer used by SendConfirmationEmail.
+func (perm *Permissions) SetEmailSender(sender EmailSender) {
+	perm.emailSender = sender
+}
+
+// SendConfirmationEmail generates a confirmation code for username and
+// emails it to the given address using the configured EmailSender,
+// rendering the code into urlTemplate (which should contain a single
+// "%s" placeholder, e.g. "https://example.com/confirm/%s"). It returns
+// the generated code in case the caller wants to log it.
+func (perm *Permissions) SendConfirmationEmail(username, to, subject, urlTemplate string) (string, error) {
+	if perm.emailSender == nil {
+		return "", fmt.Errorf("permissions: no EmailSender configured, call SetEmailSender first")
+	}
+	code, err := perm.GenerateConfirmationCode(username)
+	if err != nil {
+		return "", err
+	}
+	body := fmt.Sprintf(urlTemplate, code)
+	if err := perm.emailSender.Send(to, subject, body); err != nil {
+		return "", fmt.Errorf("permissions: could not send confirmation email: %s", err)
+	}
+	return code, nil
+}
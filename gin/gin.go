@@ -0,0 +1,22 @@
+// Package gin provides Gin middleware for github.com/grengojbo/permissions2.
+package gin
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/grengojbo/permissions2"
+)
+
+// Handler returns Gin middleware that rejects requests perm.Rejected
+// disallows, calling perm's deny function instead of aborting with a
+// bare status code so SetDenyFunction keeps working the same as it
+// does for the other framework adapters.
+func Handler(perm *permissions.Permissions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if perm.Rejected(c.Writer, c.Request) {
+			perm.DenyFunction()(c.Writer, c.Request)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
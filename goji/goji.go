@@ -0,0 +1,22 @@
+// Package goji provides Goji middleware for github.com/grengojbo/permissions2.
+package goji
+
+import (
+	"net/http"
+
+	"github.com/grengojbo/permissions2"
+)
+
+// Handler returns a Goji-compatible middleware (func(http.Handler) http.Handler,
+// usable with goji.Use) that rejects requests perm.Rejected disallows.
+func Handler(perm *permissions.Permissions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if perm.Rejected(w, req) {
+				perm.DenyFunction()(w, req)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package permissions
+
+// Hasher hashes and verifies passwords, and flags hashes that no
+// longer meet the current policy so callers can transparently upgrade
+// them (e.g. after raising the bcrypt cost or switching algorithms).
+type Hasher interface {
+	Hash(password string) (string, error)
+	Verify(hash, password string) bool
+	NeedsRehash(hash string) bool
+}
+
+// hasherOrDefault returns perm's configured Hasher, falling back to a
+// default-cost bcrypt hasher if none was set via SetHasher or
+// Options.PasswordHasher.
+func (perm *Permissions) hasherOrDefault() Hasher {
+	if perm.hasher == nil {
+		perm.hasher = NewBcryptHasher(0)
+	}
+	return perm.hasher
+}
+
+// SetHasher changes the password Hasher used by HashPassword,
+// VerifyPassword and LoginAndRehash.
+func (perm *Permissions) SetHasher(h Hasher) {
+	perm.hasher = h
+}
+
+// HashPassword hashes password with perm's configured Hasher, for
+// storing via UserState's own password fields (e.g. AddUser).
+func (perm *Permissions) HashPassword(password string) (string, error) {
+	return perm.hasherOrDefault().Hash(password)
+}
+
+// VerifyPassword checks password against a previously hashed value.
+func (perm *Permissions) VerifyPassword(hash, password string) bool {
+	return perm.hasherOrDefault().Verify(hash, password)
+}
+
+// LoginAndRehash verifies password against username's stored password
+// hash, and if it matches but the hash no longer meets the current
+// Hasher's policy (e.g. a lower bcrypt cost, or an older algorithm
+// entirely), re-hashes and persists it with the current policy. Use
+// this in place of checking UserState's stored hash directly so that
+// tightening PasswordHasher's parameters upgrades accounts as their
+// users log in, rather than requiring a one-off migration.
+func (perm *Permissions) LoginAndRehash(username, password string) (bool, error) {
+	hash, err := perm.state.PasswordHash(username)
+	if err != nil {
+		return false, err
+	}
+	hasher := perm.hasherOrDefault()
+	verified := hasher.Verify(hash, password)
+	if !verified && hasher.NeedsRehash(hash) {
+		// The stored hash may come from another of this package's algorithms.
+		for _, old := range []Hasher{&BcryptHasher{}, &ScryptHasher{}, &Argon2idHasher{}} {
+			if old.Verify(hash, password) {
+				verified = true
+				break
+			}
+		}
+	}
+	if !verified {
+		return false, nil
+	}
+	if hasher.NeedsRehash(hash) {
+		newHash, err := hasher.Hash(password)
+		if err != nil {
+			return true, err
+		}
+		perm.state.SetPassword(username, newHash)
+	}
+	return true, nil
+}
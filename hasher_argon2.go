@@ -0,0 +1,104 @@
+package permissions
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2idHasher hashes passwords with argon2id, OWASP's current
+// recommendation, with tunable memory/time/parallelism cost parameters.
+type Argon2idHasher struct {
+	Memory      uint32 // KiB
+	Time        uint32 // iterations
+	Parallelism uint8
+	KeyLen      uint32
+	SaltLen     uint32
+}
+
+// NewArgon2idHasher creates an Argon2idHasher. A zero value for any
+// parameter falls back to the OWASP-recommended defaults (19 MiB
+// memory, 2 iterations, parallelism 1, 32-byte key, 16-byte salt).
+func NewArgon2idHasher(memory, time uint32, parallelism uint8, keyLen, saltLen uint32) *Argon2idHasher {
+	if memory == 0 {
+		memory = 19 * 1024
+	}
+	if time == 0 {
+		time = 2
+	}
+	if parallelism == 0 {
+		parallelism = 1
+	}
+	if keyLen == 0 {
+		keyLen = 32
+	}
+	if saltLen == 0 {
+		saltLen = 16
+	}
+	return &Argon2idHasher{Memory: memory, Time: time, Parallelism: parallelism, KeyLen: keyLen, SaltLen: saltLen}
+}
+
+// PHC-like format: $argon2id$v=19$m=<memory>,t=<time>,p=<parallelism>$<salt>$<key>
+const argon2idPrefix = "$argon2id$"
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("permissions: could not generate argon2id salt: %s", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Parallelism, h.KeyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version, h.Memory, h.Time, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func parseArgon2idHash(hash string) (version int, memory, timeCost uint32, parallelism uint8, salt, key []byte, ok bool) {
+	if !strings.HasPrefix(hash, argon2idPrefix) {
+		return 0, 0, 0, 0, nil, nil, false
+	}
+	parts := strings.Split(strings.TrimPrefix(hash, argon2idPrefix), "$")
+	if len(parts) != 4 {
+		return 0, 0, 0, 0, nil, nil, false
+	}
+	if _, err := fmt.Sscanf(parts[0], "v=%d", &version); err != nil {
+		return 0, 0, 0, 0, nil, nil, false
+	}
+	var p uint32
+	if _, err := fmt.Sscanf(parts[1], "m=%d,t=%d,p=%d", &memory, &timeCost, &p); err != nil {
+		return 0, 0, 0, 0, nil, nil, false
+	}
+	parallelism = uint8(p)
+	var err error
+	salt, err = base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return 0, 0, 0, 0, nil, nil, false
+	}
+	key, err = base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return 0, 0, 0, 0, nil, nil, false
+	}
+	return version, memory, timeCost, parallelism, salt, key, true
+}
+
+func (h *Argon2idHasher) Verify(hash, password string) bool {
+	_, memory, timeCost, parallelism, salt, key, ok := parseArgon2idHash(hash)
+	if !ok {
+		return false
+	}
+	derived := argon2.IDKey([]byte(password), salt, timeCost, memory, parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(derived, key) == 1
+}
+
+// NeedsRehash reports whether hash used weaker cost parameters than h's
+// current Memory/Time/Parallelism, or isn't a recognized argon2id hash.
+func (h *Argon2idHasher) NeedsRehash(hash string) bool {
+	version, memory, timeCost, parallelism, _, _, ok := parseArgon2idHash(hash)
+	if !ok {
+		return true
+	}
+	return version != argon2.Version || memory < h.Memory || timeCost < h.Time || parallelism < h.Parallelism
+}
@@ -0,0 +1,39 @@
+package permissions
+
+import "golang.org/x/crypto/bcrypt"
+
+// BcryptHasher is the default Hasher, with an adjustable cost factor.
+type BcryptHasher struct {
+	Cost int
+}
+
+// NewBcryptHasher creates a BcryptHasher with the given cost. A cost
+// of 0 uses bcrypt.DefaultCost.
+func NewBcryptHasher(cost int) *BcryptHasher {
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	return &BcryptHasher{Cost: cost}
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h *BcryptHasher) Verify(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// NeedsRehash reports whether hash was generated with a lower cost
+// than h.Cost, or isn't a bcrypt hash at all.
+func (h *BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return true
+	}
+	return cost < h.Cost
+}
@@ -0,0 +1,97 @@
+package permissions
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ScryptHasher hashes passwords with scrypt, storing N/r/p and a
+// random salt alongside the derived key so Verify doesn't need them
+// passed in separately.
+type ScryptHasher struct {
+	N, R, P int
+	KeyLen  int
+}
+
+// NewScryptHasher creates a ScryptHasher with the given scrypt cost
+// parameters. N=0 defaults to 32768, r=0 to 8, p=0 to 1, keyLen=0 to 32,
+// scrypt's recommended interactive-login settings.
+func NewScryptHasher(N, r, p, keyLen int) *ScryptHasher {
+	if N == 0 {
+		N = 32768
+	}
+	if r == 0 {
+		r = 8
+	}
+	if p == 0 {
+		p = 1
+	}
+	if keyLen == 0 {
+		keyLen = 32
+	}
+	return &ScryptHasher{N: N, R: r, P: p, KeyLen: keyLen}
+}
+
+// scrypt$N$r$p$salt$key, salt and key base64-encoded (RawStdEncoding).
+const scryptPrefix = "scrypt"
+
+func (h *ScryptHasher) Hash(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("permissions: could not generate scrypt salt: %s", err)
+	}
+	key, err := scrypt.Key([]byte(password), salt, h.N, h.R, h.P, h.KeyLen)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s$%d$%d$%d$%s$%s", scryptPrefix, h.N, h.R, h.P,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func parseScryptHash(hash string) (n, r, p int, salt, key []byte, ok bool) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != scryptPrefix {
+		return 0, 0, 0, nil, nil, false
+	}
+	if _, err := fmt.Sscanf(parts[1]+" "+parts[2]+" "+parts[3], "%d %d %d", &n, &r, &p); err != nil {
+		return 0, 0, 0, nil, nil, false
+	}
+	var err error
+	salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return 0, 0, 0, nil, nil, false
+	}
+	key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return 0, 0, 0, nil, nil, false
+	}
+	return n, r, p, salt, key, true
+}
+
+func (h *ScryptHasher) Verify(hash, password string) bool {
+	n, r, p, salt, key, ok := parseScryptHash(hash)
+	if !ok {
+		return false
+	}
+	derived, err := scrypt.Key([]byte(password), salt, n, r, p, len(key))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(derived, key) == 1
+}
+
+// NeedsRehash reports whether hash used weaker parameters than h's
+// current N/r/p, or isn't a recognized scrypt hash at all.
+func (h *ScryptHasher) NeedsRehash(hash string) bool {
+	n, r, p, _, _, ok := parseScryptHash(hash)
+	if !ok {
+		return true
+	}
+	return n < h.N || r < h.R || p < h.P
+}
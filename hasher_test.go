@@ -0,0 +1,63 @@
+package permissions
+
+import "testing"
+
+func TestBcryptHasherRoundTrip(t *testing.T) {
+	h := NewBcryptHasher(4) // low cost so the test stays fast
+	hash, err := h.Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("Hash: %s", err)
+	}
+	if !h.Verify(hash, "correct horse battery staple") {
+		t.Errorf("expected Verify to accept the correct password")
+	}
+	if h.Verify(hash, "wrong password") {
+		t.Errorf("expected Verify to reject the wrong password")
+	}
+	if h.NeedsRehash(hash) {
+		t.Errorf("expected a freshly hashed password not to need a rehash")
+	}
+	if stronger := NewBcryptHasher(5); !stronger.NeedsRehash(hash) {
+		t.Errorf("expected a higher-cost policy to flag the old hash for rehash")
+	}
+}
+
+func TestScryptHasherRoundTrip(t *testing.T) {
+	h := NewScryptHasher(16, 8, 1, 32) // low N so the test stays fast
+	hash, err := h.Hash("hunter2")
+	if err != nil {
+		t.Fatalf("Hash: %s", err)
+	}
+	if !h.Verify(hash, "hunter2") {
+		t.Errorf("expected Verify to accept the correct password")
+	}
+	if h.Verify(hash, "wrong") {
+		t.Errorf("expected Verify to reject the wrong password")
+	}
+	if h.NeedsRehash(hash) {
+		t.Errorf("expected a freshly hashed password not to need a rehash")
+	}
+	if stronger := NewScryptHasher(32, 8, 1, 32); !stronger.NeedsRehash(hash) {
+		t.Errorf("expected a higher-N policy to flag the old hash for rehash")
+	}
+}
+
+func TestArgon2idHasherRoundTrip(t *testing.T) {
+	h := NewArgon2idHasher(8*1024, 1, 1, 32, 16) // low cost so the test stays fast
+	hash, err := h.Hash("swordfish")
+	if err != nil {
+		t.Fatalf("Hash: %s", err)
+	}
+	if !h.Verify(hash, "swordfish") {
+		t.Errorf("expected Verify to accept the correct password")
+	}
+	if h.Verify(hash, "wrong") {
+		t.Errorf("expected Verify to reject the wrong password")
+	}
+	if h.NeedsRehash(hash) {
+		t.Errorf("expected a freshly hashed password not to need a rehash")
+	}
+	if stronger := NewArgon2idHasher(16*1024, 1, 1, 32, 16); !stronger.NeedsRehash(hash) {
+		t.Errorf("expected a higher-memory policy to flag the old hash for rehash")
+	}
+}
@@ -0,0 +1,64 @@
+// Package macaron provides Macaron middleware for github.com/grengojbo/permissions2.
+//
+// This is the only place in the module tree that imports
+// github.com/Unknwon/macaron; consumers of other frameworks (see the
+// sibling gin, echo, goji, martini and nethttp packages) don't pay for it.
+package macaron
+
+import (
+	"github.com/Unknwon/macaron"
+	"github.com/grengojbo/permissions2"
+)
+
+func prepareOptions(options []permissions.Options) permissions.Options {
+	var opt permissions.Options
+	if len(options) > 0 {
+		opt = options[0]
+	}
+	if len(opt.Section) == 0 {
+		opt.Section = "security"
+	}
+	sec := macaron.Config().Section(opt.Section)
+
+	if len(opt.Adapter) == 0 {
+		opt.Adapter = sec.Key("PERMISSIONS_ADAPTER").MustString("redis")
+	}
+	if opt.Interval == 0 {
+		opt.Interval = sec.Key("INTERVAL").MustInt(60)
+	}
+	if opt.Db == 0 {
+		opt.Db = sec.Key("PERMISSIONS_DB").MustInt(0)
+	}
+	if len(opt.Host) == 0 {
+		opt.Host = sec.Key("PERMISSIONS_HOST").MustString("127.0.0.1")
+	}
+	if opt.Port == 0 {
+		opt.Port = sec.Key("PERMISSIONS_PORT").MustInt(6379)
+	}
+	if len(opt.AdapterConfig) == 0 {
+		opt.AdapterConfig = sec.Key("PERMISSIONS_CONFIG").MustString("")
+	}
+
+	return opt
+}
+
+// Permissioner builds a Permissions struct from options (falling back
+// to Macaron's own config system for anything left unset) and maps it
+// into the Macaron context, the same way it worked before permissions2
+// split its framework adapters into subpackages.
+func Permissioner(options ...permissions.Options) macaron.Handler {
+	opt := prepareOptions(options)
+	perm := permissions.NewWithConf(opt)
+	return func(ctx *macaron.Context) {
+		ctx.Map(perm)
+	}
+}
+
+// Handler maps an already-constructed *permissions.Permissions into
+// the Macaron context, for callers who built it themselves (e.g. via
+// permissions.NewWithDSN).
+func Handler(perm *permissions.Permissions) macaron.Handler {
+	return func(ctx *macaron.Context) {
+		ctx.Map(perm)
+	}
+}
@@ -0,0 +1,22 @@
+// Package martini provides Martini middleware for github.com/grengojbo/permissions2.
+package martini
+
+import (
+	"net/http"
+
+	"github.com/go-martini/martini"
+	"github.com/grengojbo/permissions2"
+)
+
+// Handler returns Martini middleware that rejects requests
+// perm.Rejected disallows, calling perm's deny function instead of
+// c.Next() so the rest of the chain doesn't run.
+func Handler(perm *permissions.Permissions) martini.Handler {
+	return func(c martini.Context, w http.ResponseWriter, req *http.Request) {
+		if perm.Rejected(w, req) {
+			perm.DenyFunction()(w, req)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,178 @@
+package permissions
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Verdict is what a matcher decides once its pattern and method match.
+type Verdict int
+
+const (
+	// Allow grants the request outright, with no further rights check.
+	// Public path matchers always carry this verdict.
+	Allow Verdict = iota
+	// Deny means the request is rejected unless the matcher's rights
+	// check (admin rights, user rights, or a role) passes.
+	Deny
+)
+
+// pathKind records which category (admin, user, public or role) a
+// matcher was registered under, so that SetAdminPath/SetUserPath/
+// SetPublicPath can replace just their own category of matchers.
+type pathKind int
+
+const (
+	kindAdmin pathKind = iota
+	kindUser
+	kindPublic
+	kindRole
+)
+
+// matcher is one entry of perm.matchers, Permissions' single ordered
+// access-control list. Matchers are evaluated in registration order;
+// the first one whose pattern and method match a request decides its
+// fate, which is what lets e.g. a public GET matcher registered before
+// a catch-all user matcher carve out an exception for that one route.
+type matcher struct {
+	kind    pathKind
+	role    string // only set when kind == kindRole
+	re      *regexp.Regexp
+	methods map[string]bool // nil/empty means all methods
+	verdict Verdict
+}
+
+// compilePathPattern turns prefix ("/repo") or glob ("/api/*/settings")
+// patterns into a regexp. Without a "*" the pattern keeps the historic
+// strings.HasPrefix behavior of AddAdminPath/AddUserPath/AddPublicPath;
+// with one, each "*" matches any run of characters (including "/" and
+// the empty string) and the whole pattern is anchored so it doesn't
+// turn into an accidental prefix match.
+func compilePathPattern(pattern string) *regexp.Regexp {
+	if !strings.Contains(pattern, "*") {
+		return regexp.MustCompile("^" + regexp.QuoteMeta(pattern))
+	}
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
+
+// methodSet turns a possibly-empty list of HTTP methods into a lookup
+// set. An empty list means "every method".
+func methodSet(methods []string) map[string]bool {
+	if len(methods) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		set[m] = true
+	}
+	return set
+}
+
+func (m matcher) matches(req *http.Request, path string) bool {
+	if len(m.methods) > 0 && !m.methods[req.Method] {
+		return false
+	}
+	return m.re.MatchString(path)
+}
+
+// rights reports whether req satisfies this Deny matcher's rights check.
+func (m matcher) rights(perm *Permissions, req *http.Request) bool {
+	switch m.kind {
+	case kindAdmin:
+		return perm.state.AdminRights(req)
+	case kindUser:
+		return perm.state.UserRights(req)
+	case kindRole:
+		return perm.HasRole(req, m.role)
+	default:
+		return true
+	}
+}
+
+// addMatcher appends a matcher for an already compiled re. Callers that
+// take a glob/prefix pattern string (like AddAdminPathMatcher) compile
+// it with compilePathPattern first.
+func (perm *Permissions) addMatcher(kind pathKind, role string, re *regexp.Regexp, verdict Verdict, methods []string) {
+	perm.matchers = append(perm.matchers, matcher{
+		kind:    kind,
+		role:    role,
+		re:      re,
+		methods: methodSet(methods),
+		verdict: verdict,
+	})
+}
+
+// AddAdminPathMatcher registers pattern (a glob like "/api/v1/orgs/*/settings"
+// or a plain prefix like "/admin") as requiring admin rights, optionally
+// restricted to the given HTTP methods (all methods if none are given).
+func (perm *Permissions) AddAdminPathMatcher(pattern string, methods ...string) {
+	perm.addMatcher(kindAdmin, "", compilePathPattern(pattern), Deny, methods)
+}
+
+// AddAdminPathRegexp is AddAdminPathMatcher for callers that already
+// have a compiled *regexp.Regexp.
+func (perm *Permissions) AddAdminPathRegexp(re *regexp.Regexp, methods ...string) {
+	perm.addMatcher(kindAdmin, "", re, Deny, methods)
+}
+
+// AddUserPathMatcher registers pattern as requiring user rights,
+// optionally restricted to the given HTTP methods.
+func (perm *Permissions) AddUserPathMatcher(pattern string, methods ...string) {
+	perm.addMatcher(kindUser, "", compilePathPattern(pattern), Deny, methods)
+}
+
+// AddUserPathRegexp is AddUserPathMatcher for callers that already have
+// a compiled *regexp.Regexp.
+func (perm *Permissions) AddUserPathRegexp(re *regexp.Regexp, methods ...string) {
+	perm.addMatcher(kindUser, "", re, Deny, methods)
+}
+
+// AddPublicPathMatcher registers pattern as public (Allow), optionally
+// restricted to the given HTTP methods. Register it before a broader
+// admin/user matcher that would otherwise shadow it, e.g. to
+// publicly expose "GET /repo/status" while "POST /repo/*" still
+// requires user rights. Role matchers are checked before public ones
+// whatever the registration order, so a public matcher cannot carve an
+// exception out of a role matcher.
+func (perm *Permissions) AddPublicPathMatcher(pattern string, methods ...string) {
+	perm.addMatcher(kindPublic, "", compilePathPattern(pattern), Allow, methods)
+}
+
+// AddPublicPathRegexp is AddPublicPathMatcher for callers that already
+// have a compiled *regexp.Regexp.
+func (perm *Permissions) AddPublicPathRegexp(re *regexp.Regexp, methods ...string) {
+	perm.addMatcher(kindPublic, "", re, Allow, methods)
+}
+
+// AddRolePathMatcher registers pattern as requiring role, optionally
+// restricted to the given HTTP methods. role is registered via AddRole
+// if it hasn't been already.
+func (perm *Permissions) AddRolePathMatcher(role, pattern string, methods ...string) {
+	perm.AddRole(role)
+	perm.addMatcher(kindRole, role, compilePathPattern(pattern), Deny, methods)
+}
+
+// AddRolePathRegexp is AddRolePathMatcher for callers that already have
+// a compiled *regexp.Regexp.
+func (perm *Permissions) AddRolePathRegexp(role string, re *regexp.Regexp, methods ...string) {
+	perm.AddRole(role)
+	perm.addMatcher(kindRole, role, re, Deny, methods)
+}
+
+// replaceKind drops every matcher of the given kind, in place of the
+// old SetAdminPath/SetUserPath/SetPublicPath "replace the whole list"
+// behavior.
+func (perm *Permissions) replaceKind(kind pathKind) {
+	kept := perm.matchers[:0]
+	for _, m := range perm.matchers {
+		if m.kind != kind {
+			kept = append(kept, m)
+		}
+	}
+	perm.matchers = kept
+}
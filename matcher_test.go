@@ -0,0 +1,97 @@
+package permissions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grengojbo/pinterface"
+)
+
+// fakeState is a minimal pinterface.IUserState for exercising
+// Permissions/matcher logic without a real backend. Embedding the
+// interface (left nil) satisfies methods this package's tests don't
+// care about; only the ones Rejected/HasRole actually call are
+// overridden below.
+type fakeState struct {
+	pinterface.IUserState
+	adminOK  bool
+	userOK   bool
+	username string
+}
+
+func (f *fakeState) AdminRights(req *http.Request) bool { return f.adminOK }
+func (f *fakeState) UserRights(req *http.Request) bool  { return f.userOK }
+func (f *fakeState) Username(req *http.Request) string  { return f.username }
+
+func TestCompilePathPatternPrefix(t *testing.T) {
+	re := compilePathPattern("/repo")
+	for _, path := range []string{"/repo", "/repo/status"} {
+		if !re.MatchString(path) {
+			t.Errorf("expected prefix pattern %q to match %q", "/repo", path)
+		}
+	}
+}
+
+func TestCompilePathPatternGlob(t *testing.T) {
+	re := compilePathPattern("/api/v1/orgs/*/settings")
+	if !re.MatchString("/api/v1/orgs/42/settings") {
+		t.Errorf("expected the glob pattern to match a concrete org id")
+	}
+	if re.MatchString("/api/v1/orgs/42/settings/extra") {
+		t.Errorf("expected the glob pattern to be anchored at the end")
+	}
+}
+
+func TestMatcherMethodWhitelist(t *testing.T) {
+	m := matcher{re: compilePathPattern("/repo"), methods: methodSet([]string{"GET"})}
+	get := httptest.NewRequest("GET", "/repo/status", nil)
+	post := httptest.NewRequest("POST", "/repo/status", nil)
+	if !m.matches(get, get.URL.Path) {
+		t.Errorf("expected GET to match the GET-only matcher")
+	}
+	if m.matches(post, post.URL.Path) {
+		t.Errorf("expected POST not to match the GET-only matcher")
+	}
+}
+
+func TestRejectedPublicExceptionMustBeRegisteredFirst(t *testing.T) {
+	perm := NewPermissions(&fakeState{})
+	perm.matchers = nil
+	perm.AddPublicPathMatcher("/repo/status", "GET")
+	perm.AddUserPathMatcher("/repo")
+
+	get := httptest.NewRequest("GET", "/repo/status", nil)
+	if perm.Rejected(httptest.NewRecorder(), get) {
+		t.Errorf("expected GET /repo/status to be public")
+	}
+	post := httptest.NewRequest("POST", "/repo/status", nil)
+	if !perm.Rejected(httptest.NewRecorder(), post) {
+		t.Errorf("expected POST /repo/status to still require user rights")
+	}
+}
+
+func TestRejectedRoleTakesPriorityOverDefaultUserPath(t *testing.T) {
+	state := &fakeState{username: "alice"} // no admin/user rights
+	perm := NewPermissions(state)
+	perm.AddRolePath("auditor", "/repo")
+	if err := perm.AssignRole("alice", "auditor"); err != nil {
+		t.Fatalf("AssignRole: %s", err)
+	}
+
+	req := httptest.NewRequest("GET", "/repo/status", nil)
+	if perm.Rejected(httptest.NewRecorder(), req) {
+		t.Errorf("expected the auditor role to grant access to /repo despite lacking user rights")
+	}
+}
+
+func TestRejectedDeniesUnassignedRole(t *testing.T) {
+	state := &fakeState{username: "bob"}
+	perm := NewPermissions(state)
+	perm.AddRolePath("auditor", "/repo")
+
+	req := httptest.NewRequest("GET", "/repo/status", nil)
+	if !perm.Rejected(httptest.NewRecorder(), req) {
+		t.Errorf("expected bob, who lacks the auditor role and user rights, to be rejected")
+	}
+}
@@ -0,0 +1,24 @@
+// Package nethttp provides plain net/http middleware for
+// github.com/grengojbo/permissions2, for applications that don't use
+// any of the supported frameworks.
+package nethttp
+
+import (
+	"net/http"
+
+	"github.com/grengojbo/permissions2"
+)
+
+// Handler wraps next with a standard func(http.Handler) http.Handler
+// middleware that rejects requests perm.Rejected disallows.
+func Handler(perm *permissions.Permissions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if perm.Rejected(w, req) {
+				perm.DenyFunction()(w, req)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
@@ -4,9 +4,8 @@ package permissions
 import (
 	"fmt"
 	"net/http"
-	"strings"
+	"time"
 
-	"github.com/Unknwon/macaron"
 	"github.com/grengojbo/pinterface"
 )
 
@@ -28,60 +27,49 @@ type Options struct {
 	Db      int
 	Host    string
 	Port    int
-}
-
-func prepareOptions(options []Options) Options {
-	var opt Options
-	if len(options) > 0 {
-		opt = options[0]
-	}
+	// ConfirmationTTL is how long a generated confirmation code stays
+	// valid. Default is 24 hours.
+	ConfirmationTTL time.Duration
+	// PasswordHasher hashes and verifies passwords. Default is
+	// NewBcryptHasher(0).
+	PasswordHasher Hasher
+}
+
+// applyOptionDefaults fills in zero-valued fields of opt with the
+// package defaults. Framework adapters that can source these values
+// from their own config system (see permissions/macaron) should do
+// that first and only fall back to this for whatever's left unset.
+func applyOptionDefaults(opt Options) Options {
 	if len(opt.Section) == 0 {
 		opt.Section = "security"
 	}
-	sec := macaron.Config().Section(opt.Section)
-
 	if len(opt.Adapter) == 0 {
-		opt.Adapter = sec.Key("PERMISSIONS_ADAPTER").MustString("redis")
+		opt.Adapter = "redis"
 	}
 	if opt.Interval == 0 {
-		opt.Interval = sec.Key("INTERVAL").MustInt(60)
-	}
-	if opt.Db == 0 {
-		opt.Db = sec.Key("PERMISSIONS_DB").MustInt(0)
+		opt.Interval = 60
 	}
 	if len(opt.Host) == 0 {
-		opt.Host = sec.Key("PERMISSIONS_HOST").MustString("127.0.0.1")
+		opt.Host = "127.0.0.1"
 	}
 	if opt.Port == 0 {
-		opt.Port = sec.Key("PERMISSIONS_PORT").MustInt(6379)
-	}
-	if len(opt.AdapterConfig) == 0 {
-		opt.AdapterConfig = sec.Key("PERMISSIONS_CONFIG").MustString("")
+		opt.Port = 6379
 	}
-
 	return opt
 }
 
 // The structure that keeps track of the permissions for various path prefixes
 type Permissions struct {
-	state              *UserState
-	adminPathPrefixes  []string
-	userPathPrefixes   []string
-	publicPathPrefixes []string
-	rootIsPublic       bool
-	denied             http.HandlerFunc
-}
-
-func Permissioner(options ...Options) macaron.Handler {
-	opt := prepareOptions(options)
-	perm := NewWithConf(opt)
-	// perm, err := NewWithConf(opt)
-	// if err != nil {
-	// 	panic(err)
-	// }
-	return func(ctx *macaron.Context) {
-		ctx.Map(perm)
-	}
+	state           pinterface.IUserState
+	matchers        []matcher
+	rootIsPublic    bool
+	denied          http.HandlerFunc
+	confirmations   *confirmationExpiry
+	confirmationTTL time.Duration
+	emailSender     EmailSender
+	knownRoles      map[string]bool
+	localRoles      *localRoleStore
+	hasher          Hasher
 }
 
 // Initialize a Permissions struct with all the default settings.
@@ -90,9 +78,25 @@ func New() *Permissions {
 	return NewPermissions(NewUserStateSimple())
 }
 
-// Initialize a Permissions struct with config
+// Initialize a Permissions struct with config. The adapter named by
+// opt.Adapter is looked up in the adapter registry (see RegisterAdapter),
+// defaulting to "redis", and a GC goroutine is started for it when it
+// implements GCStater.
 func NewWithConf(opt Options) *Permissions {
-	return NewPermissions(NewUserState(opt.Db, true, fmt.Sprintf("%s:%d", opt.Host, opt.Port)))
+	opt = applyOptionDefaults(opt)
+	dsn := opt.AdapterConfig
+	if len(dsn) == 0 {
+		dsn = redisDSN(opt.Db, fmt.Sprintf("%s:%d", opt.Host, opt.Port))
+	}
+	perm, err := NewWithDSN(opt.Adapter, dsn)
+	if err != nil {
+		panic(err)
+	}
+	perm.confirmationTTL = opt.ConfirmationTTL
+	perm.hasher = opt.PasswordHasher
+	startGC(perm.state, opt.Interval)
+	startGC(perm.confirmationExpiryTracker(), opt.Interval)
+	return perm
 }
 
 // Initialize a Permissions struct with Redis DB index and host:port
@@ -102,15 +106,21 @@ func NewWithRedisConf(dbindex int, hostPort string) *Permissions {
 
 // Initialize a Permissions struct with the given UserState and
 // a few default paths for admin/user/public path prefixes.
-func NewPermissions(state *UserState) *Permissions {
+func NewPermissions(state pinterface.IUserState) *Permissions {
+	perm := &Permissions{
+		state:        state,
+		rootIsPublic: true,
+		denied:       PermissionDenied,
+	}
 	// default permissions
-	return &Permissions{state,
-		[]string{"/admin"},         // admin path prefixes
-		[]string{"/repo", "/data"}, // user path prefixes
-		[]string{"/", "/login", "/register", "/favicon.ico", "/style", "/img", "/js",
-			"/favicon.ico", "/robots.txt", "/sitemap_index.xml"}, // public
-		true,
-		PermissionDenied}
+	perm.AddAdminPath("/admin")
+	perm.AddUserPath("/repo")
+	perm.AddUserPath("/data")
+	for _, prefix := range []string{"/", "/login", "/register", "/favicon.ico", "/style", "/img", "/js",
+		"/favicon.ico", "/robots.txt", "/sitemap_index.xml"} {
+		perm.AddPublicPath(prefix)
+	}
+	return perm
 }
 
 // Specify the http.HandlerFunc for when the permissions are denied
@@ -123,45 +133,54 @@ func (perm *Permissions) DenyFunction() http.HandlerFunc {
 	return perm.denied
 }
 
-// Retrieve the UserState struct
+// Retrieve the UserState
 func (perm *Permissions) UserState() pinterface.IUserState {
 	return perm.state
 }
 
 // Set everything to public
 func (perm *Permissions) Clear() {
-	perm.adminPathPrefixes = []string{}
-	perm.userPathPrefixes = []string{}
+	perm.matchers = nil
+	perm.AddPublicPath("/")
 }
 
 // Add an url path prefix that is a page for the logged in administrators
 func (perm *Permissions) AddAdminPath(prefix string) {
-	perm.adminPathPrefixes = append(perm.adminPathPrefixes, prefix)
+	perm.AddAdminPathMatcher(prefix)
 }
 
 // Add an url path prefix that is a page for the logged in users
 func (perm *Permissions) AddUserPath(prefix string) {
-	perm.userPathPrefixes = append(perm.userPathPrefixes, prefix)
+	perm.AddUserPathMatcher(prefix)
 }
 
 // Add an url path prefix that is a public page
 func (perm *Permissions) AddPublicPath(prefix string) {
-	perm.publicPathPrefixes = append(perm.publicPathPrefixes, prefix)
+	perm.AddPublicPathMatcher(prefix)
 }
 
 // Set all url path prefixes that are for the logged in administrator pages
 func (perm *Permissions) SetAdminPath(pathPrefixes []string) {
-	perm.adminPathPrefixes = pathPrefixes
+	perm.replaceKind(kindAdmin)
+	for _, prefix := range pathPrefixes {
+		perm.AddAdminPath(prefix)
+	}
 }
 
 // Set all url path prefixes that are for the logged in user pages
 func (perm *Permissions) SetUserPath(pathPrefixes []string) {
-	perm.userPathPrefixes = pathPrefixes
+	perm.replaceKind(kindUser)
+	for _, prefix := range pathPrefixes {
+		perm.AddUserPath(prefix)
+	}
 }
 
 // Set all url path prefixes that are for the public pages
 func (perm *Permissions) SetPublicPath(pathPrefixes []string) {
-	perm.publicPathPrefixes = pathPrefixes
+	perm.replaceKind(kindPublic)
+	for _, prefix := range pathPrefixes {
+		perm.AddPublicPath(prefix)
+	}
 }
 
 // The default "permission denied" http handler.
@@ -170,52 +189,44 @@ func PermissionDenied(w http.ResponseWriter, req *http.Request) {
 }
 
 // Check if a given request should be rejected.
+//
+// Matchers registered via AddAdminPath/AddUserPath/AddPublicPath/
+// AddRolePath and their *Matcher/*Regexp variants are evaluated in
+// registration order; the first one whose pattern and method match
+// the request decides the verdict (Allow, or Deny unless its rights
+// check passes). A path that matches no matcher at all is rejected.
+//
+// Role matchers (AddRolePath et al.) take priority over the
+// admin/user/public matchers regardless of registration order, so
+// that a role can carve out an exception under a path NewPermissions
+// already guards by default (e.g. "/repo").
 func (perm *Permissions) Rejected(w http.ResponseWriter, req *http.Request) bool {
-	reject := false
 	path := req.URL.Path // the path of the url that the user wish to visit
 
 	// If it's not "/" and set to be public regardless of permissions
-	if !(perm.rootIsPublic && path == "/") {
-
-		// Reject if it is an admin page and user does not have admin permissions
-		for _, prefix := range perm.adminPathPrefixes {
-			if strings.HasPrefix(path, prefix) {
-				if !perm.state.AdminRights(req) {
-					reject = true
-					break
-				}
-			}
-		}
+	if perm.rootIsPublic && path == "/" {
+		return false
+	}
 
-		if !reject {
-			// Reject if it's a user page and the user does not have user rights
-			for _, prefix := range perm.userPathPrefixes {
-				if strings.HasPrefix(path, prefix) {
-					if !perm.state.UserRights(req) {
-						reject = true
-						break
-					}
-				}
-			}
+	for _, m := range perm.matchers {
+		if m.kind != kindRole || !m.matches(req, path) {
+			continue
 		}
+		return !m.rights(perm, req)
+	}
 
-		if !reject {
-			// Reject if it's not a public page
-			found := false
-			for _, prefix := range perm.publicPathPrefixes {
-				if strings.HasPrefix(path, prefix) {
-					found = true
-					break
-				}
-			}
-			if !found {
-				reject = true
-			}
+	for _, m := range perm.matchers {
+		if m.kind == kindRole || !m.matches(req, path) {
+			continue
 		}
-
+		if m.verdict == Allow {
+			return false
+		}
+		return !m.rights(perm, req)
 	}
 
-	return reject
+	// No matcher matched at all: deny by default.
+	return true
 }
 
 // Middleware handler (compatible with Negroni)
@@ -0,0 +1,145 @@
+package permissions
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// RoleState is an optional extension of pinterface.IUserState for
+// backends that can persist role membership themselves. Backends that
+// don't implement it fall back to the in-memory role store kept on
+// Permissions, the same way GCStater is optional for sweeping.
+type RoleState interface {
+	Roles(username string) []string
+	AssignRole(username, role string)
+	RevokeRole(username, role string)
+}
+
+// localRoleStore is the fallback RoleState used when the configured
+// backend doesn't implement RoleState itself.
+type localRoleStore struct {
+	mu    sync.RWMutex
+	roles map[string]map[string]bool // username -> set of roles
+}
+
+func newLocalRoleStore() *localRoleStore {
+	return &localRoleStore{roles: make(map[string]map[string]bool)}
+}
+
+func (s *localRoleStore) Roles(username string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	roles := make([]string, 0, len(s.roles[username]))
+	for role := range s.roles[username] {
+		roles = append(roles, role)
+	}
+	return roles
+}
+
+func (s *localRoleStore) AssignRole(username, role string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.roles[username] == nil {
+		s.roles[username] = make(map[string]bool)
+	}
+	s.roles[username][role] = true
+}
+
+func (s *localRoleStore) RevokeRole(username, role string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.roles[username], role)
+}
+
+// roleState lazily resolves the RoleState to use: the backend itself
+// if it implements RoleState, otherwise perm's own fallback store.
+func (perm *Permissions) roleState() RoleState {
+	if rs, ok := perm.state.(RoleState); ok {
+		return rs
+	}
+	if perm.localRoles == nil {
+		perm.localRoles = newLocalRoleStore()
+	}
+	return perm.localRoles
+}
+
+// AddRole registers a role name, so that AssignRole/RevokeRole/HasRole
+// will accept it. Adding the same role twice is a no-op.
+func (perm *Permissions) AddRole(name string) {
+	if perm.knownRoles == nil {
+		perm.knownRoles = make(map[string]bool)
+	}
+	perm.knownRoles[name] = true
+}
+
+func (perm *Permissions) knowsRole(name string) bool {
+	return perm.knownRoles != nil && perm.knownRoles[name]
+}
+
+// AssignRole grants username the given role. The role must have been
+// registered with AddRole first.
+func (perm *Permissions) AssignRole(username, role string) error {
+	if !perm.knowsRole(role) {
+		return fmt.Errorf("permissions: unknown role %q, call AddRole first", role)
+	}
+	perm.roleState().AssignRole(username, role)
+	return nil
+}
+
+// RevokeRole removes role from username. Revoking a role the user
+// doesn't have is a no-op.
+func (perm *Permissions) RevokeRole(username, role string) {
+	perm.roleState().RevokeRole(username, role)
+}
+
+// HasRole reports whether the user making req has been assigned role.
+func (perm *Permissions) HasRole(req *http.Request, role string) bool {
+	username := perm.state.Username(req)
+	if username == "" {
+		return false
+	}
+	for _, has := range perm.roleState().Roles(username) {
+		if has == role {
+			return true
+		}
+	}
+	return false
+}
+
+// AddRolePath grants role access to every path with the given prefix.
+// The role must have been registered with AddRole first (AddRolePath
+// does this for you). See AddRolePathMatcher for glob patterns and
+// method restrictions.
+func (perm *Permissions) AddRolePath(role, prefix string) {
+	perm.AddRolePathMatcher(role, prefix)
+}
+
+// RequireRole returns a http.HandlerFunc guard for role: it responds
+// with perm's deny function when the request doesn't carry role, and
+// does nothing otherwise. Register it ahead of the route it's guarding
+// (e.g. via Negroni's n.UseHandlerFunc, or by calling it inline at the
+// top of your own handler) in addition to, or instead of, AddRolePath.
+// For a single http.HandlerFunc that both checks the role and calls
+// the protected handler, use RequireRoleHandler instead.
+func (perm *Permissions) RequireRole(role string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !perm.HasRole(req, role) {
+			perm.DenyFunction()(w, req)
+		}
+	}
+}
+
+// RequireRoleHandler wraps next so it's only called if the request
+// carries role, responding with perm's deny function otherwise. Use it
+// to guard individual routes in addition to (or instead of)
+// AddRolePath, e.g. with Gin/Negroni/Macaron.
+func (perm *Permissions) RequireRoleHandler(role string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !perm.HasRole(req, role) {
+			perm.DenyFunction()(w, req)
+			return
+		}
+		next(w, req)
+	}
+}
@@ -0,0 +1,93 @@
+package permissions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAssignRoleRejectsUnregisteredRole(t *testing.T) {
+	perm := NewPermissions(&fakeState{})
+	if err := perm.AssignRole("alice", "auditor"); err == nil {
+		t.Errorf("expected AssignRole to reject a role that was never registered with AddRole")
+	}
+}
+
+func TestAssignAndRevokeRole(t *testing.T) {
+	perm := NewPermissions(&fakeState{username: "alice"})
+	perm.AddRole("auditor")
+	if err := perm.AssignRole("alice", "auditor"); err != nil {
+		t.Fatalf("AssignRole: %s", err)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	if !perm.HasRole(req, "auditor") {
+		t.Errorf("expected alice to have the auditor role after AssignRole")
+	}
+
+	perm.RevokeRole("alice", "auditor")
+	if perm.HasRole(req, "auditor") {
+		t.Errorf("expected alice to have lost the auditor role after RevokeRole")
+	}
+}
+
+func TestHasRoleWithoutUsername(t *testing.T) {
+	perm := NewPermissions(&fakeState{})
+	req := httptest.NewRequest("GET", "/", nil)
+	if perm.HasRole(req, "auditor") {
+		t.Errorf("expected a request with no username to never have a role")
+	}
+}
+
+func TestRequireRoleDeniesWithoutRole(t *testing.T) {
+	perm := NewPermissions(&fakeState{username: "bob"})
+	perm.AddRole("auditor")
+
+	guard := perm.RequireRole("auditor")
+	req := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	guard(w, req)
+	if w.Code != 403 {
+		t.Errorf("expected bob, who lacks the auditor role, to be denied, got status %d", w.Code)
+	}
+}
+
+func TestRequireRoleAllowsWithRole(t *testing.T) {
+	perm := NewPermissions(&fakeState{username: "alice"})
+	perm.AddRole("auditor")
+	if err := perm.AssignRole("alice", "auditor"); err != nil {
+		t.Fatalf("AssignRole: %s", err)
+	}
+
+	guard := perm.RequireRole("auditor")
+	req := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	guard(w, req)
+	if w.Code != 200 {
+		t.Errorf("expected alice, who has the auditor role, not to be denied, got status %d", w.Code)
+	}
+}
+
+func TestRequireRoleHandlerCallsNextOnlyWithRole(t *testing.T) {
+	perm := NewPermissions(&fakeState{username: "bob"})
+	perm.AddRole("auditor")
+
+	called := false
+	wrapped := perm.RequireRoleHandler("auditor", func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	wrapped(httptest.NewRecorder(), req)
+	if called {
+		t.Errorf("expected next not to be called for bob, who lacks the auditor role")
+	}
+
+	if err := perm.AssignRole("bob", "auditor"); err != nil {
+		t.Fatalf("AssignRole: %s", err)
+	}
+	wrapped(httptest.NewRecorder(), req)
+	if !called {
+		t.Errorf("expected next to be called once bob has the auditor role")
+	}
+}
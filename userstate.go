@@ -0,0 +1,228 @@
+package permissions
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// UserState is the Redis-backed pinterface.IUserState implementation
+// that backs the "redis" adapter registered in adapters.go, New() and
+// NewWithRedisConf(). Users are tracked in a "users" set, with a
+// "user:<username>" hash per user for their password hash and flags,
+// and a "confirmationcodes" hash mapping outstanding confirmation
+// codes to the username waiting on them.
+type UserState struct {
+	pool    *redis.Pool
+	dbindex int
+}
+
+const (
+	usersKey             = "users"
+	unconfirmedUsersKey  = "users:unconfirmed"
+	confirmationCodesKey = "confirmationcodes"
+
+	// userCookieName is the cookie UserState reads the current
+	// session's username from.
+	userCookieName = "user"
+)
+
+func userKey(username string) string {
+	return "user:" + username
+}
+
+// NewUserState connects to the Redis server at hostPort (falling back
+// to "127.0.0.1:6379" if useRedis is false or hostPort is empty) and
+// selects database dbindex.
+func NewUserState(dbindex int, useRedis bool, hostPort string) *UserState {
+	if !useRedis || len(hostPort) == 0 {
+		hostPort = "127.0.0.1:6379"
+	}
+	pool := &redis.Pool{
+		MaxIdle: 3,
+		Dial: func() (redis.Conn, error) {
+			c, err := redis.Dial("tcp", hostPort)
+			if err != nil {
+				return nil, err
+			}
+			if dbindex != 0 {
+				if _, err := c.Do("SELECT", dbindex); err != nil {
+					c.Close()
+					return nil, err
+				}
+			}
+			return c, nil
+		},
+	}
+	return &UserState{pool: pool, dbindex: dbindex}
+}
+
+// NewUserStateSimple connects to a local Redis server on its default
+// port, using database 0.
+func NewUserStateSimple() *UserState {
+	return NewUserState(0, true, "127.0.0.1:6379")
+}
+
+func (state *UserState) conn() redis.Conn {
+	return state.pool.Get()
+}
+
+// HasUser reports whether username has been registered.
+func (state *UserState) HasUser(username string) bool {
+	c := state.conn()
+	defer c.Close()
+	found, _ := redis.Bool(c.Do("SISMEMBER", usersKey, username))
+	return found
+}
+
+// AddUser registers username, along with its already-hashed password
+// (see Permissions.HashPassword) and email.
+func (state *UserState) AddUser(username, passwordHash, email string) error {
+	c := state.conn()
+	defer c.Close()
+	if _, err := c.Do("SADD", usersKey, username); err != nil {
+		return err
+	}
+	_, err := c.Do("HSET", userKey(username), "password", passwordHash, "email", email)
+	return err
+}
+
+// PasswordHash returns the stored password hash for username.
+func (state *UserState) PasswordHash(username string) (string, error) {
+	c := state.conn()
+	defer c.Close()
+	hash, err := redis.String(c.Do("HGET", userKey(username), "password"))
+	if err != nil {
+		return "", fmt.Errorf("permissions: no password hash for user %q: %s", username, err)
+	}
+	return hash, nil
+}
+
+// SetPassword overwrites username's stored password hash.
+func (state *UserState) SetPassword(username, passwordHash string) {
+	c := state.conn()
+	defer c.Close()
+	c.Do("HSET", userKey(username), "password", passwordHash)
+}
+
+// IsConfirmed reports whether username has followed its confirmation link.
+func (state *UserState) IsConfirmed(username string) bool {
+	c := state.conn()
+	defer c.Close()
+	confirmed, _ := redis.Bool(c.Do("HGET", userKey(username), "confirmed"))
+	return confirmed
+}
+
+func (state *UserState) setConfirmed(username string) {
+	c := state.conn()
+	defer c.Close()
+	c.Do("HSET", userKey(username), "confirmed", true)
+}
+
+// IsAdmin reports whether username has administrator rights.
+func (state *UserState) IsAdmin(username string) bool {
+	c := state.conn()
+	defer c.Close()
+	admin, _ := redis.Bool(c.Do("HGET", userKey(username), "admin"))
+	return admin
+}
+
+// SetAdminStatus grants username administrator rights.
+func (state *UserState) SetAdminStatus(username string) {
+	c := state.conn()
+	defer c.Close()
+	c.Do("HSET", userKey(username), "admin", true)
+}
+
+// Username returns the username carried by req's session cookie, or
+// "" if there isn't one.
+func (state *UserState) Username(req *http.Request) string {
+	cookie, err := req.Cookie(userCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// UserRights reports whether req belongs to a registered, confirmed user.
+func (state *UserState) UserRights(req *http.Request) bool {
+	username := state.Username(req)
+	return username != "" && state.HasUser(username) && state.IsConfirmed(username)
+}
+
+// AdminRights reports whether req belongs to an administrator.
+func (state *UserState) AdminRights(req *http.Request) bool {
+	username := state.Username(req)
+	return username != "" && state.IsAdmin(username)
+}
+
+// GenerateUniqueConfirmationCode creates a confirmation code that
+// isn't already tied to a pending user.
+func (state *UserState) GenerateUniqueConfirmationCode() (string, error) {
+	buf := make([]byte, 20)
+	for {
+		if _, err := rand.Read(buf); err != nil {
+			return "", fmt.Errorf("permissions: could not generate confirmation code: %s", err)
+		}
+		code := hex.EncodeToString(buf)
+		if !state.AlreadyHasConfirmationCode(code) {
+			return code, nil
+		}
+	}
+}
+
+// AlreadyHasConfirmationCode reports whether confirmationCode is
+// already tied to a pending user. It's the collision check used by
+// GenerateUniqueConfirmationCode, not a username lookup.
+func (state *UserState) AlreadyHasConfirmationCode(confirmationCode string) bool {
+	c := state.conn()
+	defer c.Close()
+	found, _ := redis.Bool(c.Do("HEXISTS", confirmationCodesKey, confirmationCode))
+	return found
+}
+
+// AddUnconfirmed records that username is waiting to be confirmed
+// with confirmationCode.
+func (state *UserState) AddUnconfirmed(username, confirmationCode string) {
+	c := state.conn()
+	defer c.Close()
+	c.Do("SADD", unconfirmedUsersKey, username)
+	c.Do("HSET", confirmationCodesKey, confirmationCode, username)
+}
+
+// RemoveUnconfirmed forgets that username is waiting to be confirmed,
+// and drops any confirmation codes issued to it so they can no longer
+// be used.
+func (state *UserState) RemoveUnconfirmed(username string) {
+	c := state.conn()
+	defer c.Close()
+	c.Do("SREM", unconfirmedUsersKey, username)
+	codes, _ := redis.StringMap(c.Do("HGETALL", confirmationCodesKey))
+	for code, u := range codes {
+		if u == username {
+			c.Do("HDEL", confirmationCodesKey, code)
+		}
+	}
+}
+
+// ConfirmUserByConfirmationCode looks up the username tied to
+// confirmationCode, marks it confirmed, and forgets the code.
+func (state *UserState) ConfirmUserByConfirmationCode(confirmationCode string) error {
+	c := state.conn()
+	defer c.Close()
+	username, err := redis.String(c.Do("HGET", confirmationCodesKey, confirmationCode))
+	if err != nil {
+		return fmt.Errorf("permissions: unknown confirmation code")
+	}
+	if _, err := c.Do("HDEL", confirmationCodesKey, confirmationCode); err != nil {
+		return err
+	}
+	if _, err := c.Do("SREM", unconfirmedUsersKey, username); err != nil {
+		return err
+	}
+	state.setConfirmed(username)
+	return nil
+}